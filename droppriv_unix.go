@@ -0,0 +1,50 @@
+//go:build !windows
+
+package nodejs
+
+import (
+	"fmt"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// applyCredentials configures cmd to drop privileges to the given user/group
+// before exec, via SysProcAttr.Credential.
+func applyCredentials(cmd *exec.Cmd, userName, groupName string) error {
+	if userName == "" && groupName == "" {
+		return nil
+	}
+
+	var uid, gid int
+
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			return fmt.Errorf("failed to look up user %q: %v", userName, err)
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return fmt.Errorf("invalid uid for user %q: %v", userName, err)
+		}
+		if gid, err = strconv.Atoi(u.Gid); err != nil {
+			return fmt.Errorf("invalid gid for user %q: %v", userName, err)
+		}
+	}
+
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			return fmt.Errorf("failed to look up group %q: %v", groupName, err)
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return fmt.Errorf("invalid gid for group %q: %v", groupName, err)
+		}
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package nodejs
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// applyCredentials is unsupported on Windows: there's no SysProcAttr.Credential
+// equivalent for dropping to an arbitrary user/group before exec.
+func applyCredentials(cmd *exec.Cmd, userName, groupName string) error {
+	if userName == "" && groupName == "" {
+		return nil
+	}
+	return fmt.Errorf("user/group privilege dropping is not supported on windows")
+}
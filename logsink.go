@@ -0,0 +1,168 @@
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logSink is a destination for Node.js process output or access log entries.
+// Sinks manage their own lifecycle (reconnects, rotation) and must be closed
+// when the owning worker stops.
+type logSink interface {
+	Write(p []byte) (int, error)
+	Close() error
+}
+
+// fileSinkConfig configures a single `file` entry in a `log_output` block,
+// or the target of an `access_log` directive.
+type fileSinkConfig struct {
+	Path        string
+	RotateEvery time.Duration
+	Keep        int
+}
+
+// syslogSinkConfig configures a single `syslog` entry in a `log_output` block.
+type syslogSinkConfig struct {
+	Network  string
+	Addr     string
+	Facility string
+	Tag      string
+}
+
+// logOutputConfig is the set of sinks Node.js stdout/stderr are written to.
+type logOutputConfig struct {
+	Files   []fileSinkConfig
+	Syslogs []syslogSinkConfig
+	Stdout  bool
+	Stderr  bool
+}
+
+const (
+	defaultRotateEvery = 1 * time.Hour
+	defaultKeep        = 24
+)
+
+// fileSink writes bytes unchanged to a file whose name carries its creation
+// time, rotating to a new file every rotateEvery and pruning down to keep
+// files on each rotation.
+type fileSink struct {
+	base        string
+	ext         string
+	rotateEvery time.Duration
+	keep        int
+
+	mu     sync.Mutex
+	file   *os.File
+	stopCh chan struct{}
+}
+
+func newFileSink(path string, rotateEvery time.Duration, keep int) (*fileSink, error) {
+	if rotateEvery <= 0 {
+		rotateEvery = defaultRotateEvery
+	}
+	if keep <= 0 {
+		keep = defaultKeep
+	}
+
+	ext := filepath.Ext(path)
+	fs := &fileSink{
+		base:        strings.TrimSuffix(path, ext),
+		ext:         ext,
+		rotateEvery: rotateEvery,
+		keep:        keep,
+		stopCh:      make(chan struct{}),
+	}
+	if err := fs.rotate(); err != nil {
+		return nil, err
+	}
+	go fs.rotateLoop()
+	return fs, nil
+}
+
+func (fs *fileSink) currentPath() string {
+	return fmt.Sprintf("%s_%s%s", fs.base, time.Now().Format("20060102150405"), fs.ext)
+}
+
+func (fs *fileSink) rotate() error {
+	f, err := os.OpenFile(fs.currentPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+
+	fs.mu.Lock()
+	old := fs.file
+	fs.file = f
+	fs.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+
+	return fs.pruneOld()
+}
+
+func (fs *fileSink) pruneOld() error {
+	files, err := filepath.Glob(fs.base + "_*" + fs.ext)
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for i := 0; i < len(files)-fs.keep; i++ {
+		if err := os.Remove(files[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *fileSink) rotateLoop() {
+	for {
+		select {
+		case <-time.After(fs.rotateEvery):
+			fs.rotate()
+		case <-fs.stopCh:
+			return
+		}
+	}
+}
+
+func (fs *fileSink) Write(p []byte) (int, error) {
+	fs.mu.Lock()
+	f := fs.file
+	fs.mu.Unlock()
+	if f == nil {
+		return len(p), nil
+	}
+	return f.Write(p)
+}
+
+func (fs *fileSink) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.stopCh != nil {
+		close(fs.stopCh)
+		fs.stopCh = nil
+	}
+
+	if fs.file == nil {
+		return nil
+	}
+	err := fs.file.Close()
+	fs.file = nil
+	return err
+}
+
+// perWorkerPath inserts the worker's port into a configured sink path so
+// multiple workers writing the same `log_output` directive don't clobber
+// each other's files, e.g. "app.log" -> "app_3001.log".
+func perWorkerPath(path string, port int) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	return fmt.Sprintf("%s_%d%s", base, port, ext)
+}
@@ -1,18 +1,23 @@
 package nodejs
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/caddyserver/caddy/v2"
@@ -53,18 +58,85 @@ func init() {
 	httpcaddyfile.RegisterHandlerDirective("nodejs", parseCaddyfile)
 }
 
+// worker is a single child `node` process bound to its own port, plus the
+// bookkeeping the handler needs to keep it alive and route requests to it.
+type worker struct {
+	index       int
+	pid         int
+	port        int
+	addr        string
+	cmd         *exec.Cmd
+	proxy       *httputil.ReverseProxy
+	ready       sync.WaitGroup
+	readyErr    error
+	logSinks    []logSink
+	inflight    int32
+	lastActive  atomic.Value // time.Time
+	stopping    bool         // set before an intentional stop, so the exit watcher doesn't respawn
+	stopped     bool
+	idleStopped bool          // stopped by monitorIdleTime specifically, so nextWorker knows it's safe to lazily restart
+	stopCh      chan struct{} // closed by stopServer, so a sleeping respawn backoff wakes up and bails
+	generation  int           // bumped on every startServer call, so a stale monitorIdleTime can tell it's been superseded
+	mu          sync.Mutex
+}
+
+// err returns the worker's readiness failure, if any, once ready.Wait() returns.
+func (w *worker) err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.readyErr
+}
+
+func (w *worker) touch() {
+	w.lastActive.Store(time.Now())
+}
+
+func (w *worker) idleSince() time.Time {
+	if t, ok := w.lastActive.Load().(time.Time); ok {
+		return t
+	}
+	return time.Time{}
+}
+
+// readyCheck configures the HTTP probe used to decide when a newly started
+// worker is actually able to serve traffic, rather than just listening.
+type readyCheck struct {
+	Path           string
+	Status         int
+	Timeout        time.Duration
+	Interval       time.Duration
+	RequestTimeout time.Duration // per-probe HTTP client timeout; independent of Interval so a slow-but-healthy probe isn't starved by a short poll interval
+}
+
 type Nodejs struct {
-	File                string
-	Port                int
-	serverStopped       bool
-	serverReady         sync.WaitGroup
-	serverMutex         sync.Mutex
-	lastActive          time.Time
-	serverCmd           *exec.Cmd
-	serverAddr          string
+	File    string
+	Port    int
+	Workers int
+	NodeBin string
+	Args    []string
+	Cwd     string
+	Env     map[string]string
+	User    string
+	Group   string
+
+	ShutdownTimeout       time.Duration
+	ReadyCheck            readyCheck
+	DialTimeout           time.Duration
+	ResponseHeaderTimeout time.Duration
+	ProxyIdleTimeout      time.Duration
+	IdleTimeout           time.Duration
+	LogRotation           time.Duration
+	LogOutput             logOutputConfig
+	AccessLog             fileSinkConfig
+
+	workersMu  sync.Mutex
+	workers    []*worker
+	roundRobin uint64
+	drainWg    sync.WaitGroup
+	accessLog  logSink
+
 	timeout             time.Duration
 	logger              *zap.Logger
-	LogFileMap          map[int]*os.File
 	LogRotationDuration time.Duration
 }
 
@@ -83,297 +155,663 @@ func (n *Nodejs) Provision(ctx caddy.Context) error {
 		return err
 	}
 	n.logger = logger.Named("nodejs")
+
+	if n.Port == 0 {
+		n.Port = 3000
+	}
+	if n.Workers == 0 {
+		n.Workers = runtime.NumCPU()
+	}
+	if n.ReadyCheck.Path == "" {
+		n.ReadyCheck.Path = "/"
+	}
+	if n.ReadyCheck.Status == 0 {
+		n.ReadyCheck.Status = http.StatusOK
+	}
+	if n.ReadyCheck.Timeout == 0 {
+		n.ReadyCheck.Timeout = 30 * time.Second
+	}
+	if n.ReadyCheck.Interval == 0 {
+		n.ReadyCheck.Interval = 100 * time.Millisecond
+	}
+	if n.ReadyCheck.RequestTimeout == 0 {
+		n.ReadyCheck.RequestTimeout = 5 * time.Second
+	}
+	if n.DialTimeout == 0 {
+		n.DialTimeout = 5 * time.Second
+	}
+	if n.ResponseHeaderTimeout == 0 {
+		n.ResponseHeaderTimeout = 10 * time.Second
+	}
+	if n.ProxyIdleTimeout == 0 {
+		n.ProxyIdleTimeout = 90 * time.Second
+	}
+	if n.NodeBin == "" {
+		n.NodeBin = "node"
+	}
+
+	// n.timeout used to be left at its zero value, which meant the idle
+	// monitor's `time.Since(lastActive) > n.timeout` was always true and
+	// workers got shut down right after serving their first request.
+	if n.IdleTimeout == 0 {
+		n.timeout = 5 * time.Minute
+	} else {
+		n.timeout = n.IdleTimeout
+	}
+	if n.LogRotation > 0 {
+		n.LogRotationDuration = n.LogRotation
+	} else {
+		n.LogRotationDuration = 1 * time.Hour
+	}
+
+	if _, err := exec.LookPath(n.NodeBin); err != nil {
+		return fmt.Errorf("node binary %q not found: %v", n.NodeBin, err)
+	}
+	if _, err := os.Stat(n.File); err != nil {
+		return fmt.Errorf("script %q is not readable: %v", n.File, err)
+	}
+
+	if n.AccessLog.Path != "" {
+		accessLog, err := newFileSink(n.AccessLog.Path, n.AccessLog.RotateEvery, n.AccessLog.Keep)
+		if err != nil {
+			return fmt.Errorf("failed to open access log: %v", err)
+		}
+		n.accessLog = accessLog
+	}
+
+	n.workers = make([]*worker, n.Workers)
+	for i := 0; i < n.Workers; i++ {
+		w := &worker{index: i, port: n.Port + i}
+		w.touch()
+		n.workers[i] = w
+		if err := n.startServer(w); err != nil {
+			n.stopStartedWorkers(n.workers[:i])
+			return fmt.Errorf("failed to start worker %d: %v", i, err)
+		}
+		w.ready.Wait()
+		if err := w.err(); err != nil {
+			n.stopStartedWorkers(n.workers[:i+1])
+			return fmt.Errorf("worker %d failed readiness check: %v", i, err)
+		}
+	}
+
 	return nil
 }
 
-func (n *Nodejs) startServer() error {
-	n.logger.Debug("Starting server")
+// stopStartedWorkers stops any workers that were already started before a
+// later worker failed to come up, so a failed Provision doesn't orphan the
+// node processes that did start successfully.
+func (n *Nodejs) stopStartedWorkers(workers []*worker) {
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			n.stopServer(w)
+		}(w)
+	}
+	wg.Wait()
+}
+
+// buildProxy returns a reverse proxy targeting the worker's address. Using
+// httputil.ReverseProxy (instead of a hand-rolled http.Client + io.Copy) gets
+// us streaming responses, SSE, and WebSocket upgrade splicing for free, plus
+// per-phase dial/response-header/idle timeouts instead of one fixed deadline.
+func (n *Nodejs) buildProxy(w *worker) *httputil.ReverseProxy {
+	target, _ := url.Parse(w.addr)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: n.DialTimeout,
+		}).DialContext,
+		ResponseHeaderTimeout: n.ResponseHeaderTimeout,
+		IdleConnTimeout:       n.ProxyIdleTimeout,
+	}
+	proxy.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, err error) {
+		n.logger.Error("Failed to proxy request", zap.String("addr", w.addr), zap.Error(err))
+		rw.WriteHeader(http.StatusBadGateway)
+	}
+	return proxy
+}
+
+func (n *Nodejs) startServer(w *worker) error {
+	n.logger.Debug("Starting worker", zap.Int("index", w.index), zap.Int("port", w.port))
+
+	cmd := exec.Command(n.NodeBin, append([]string{n.File}, n.Args...)...)
+	cmd.Dir = n.Cwd
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PORT=%d", w.port))
+
+	repl := caddy.NewReplacer()
+	for key, value := range n.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, repl.ReplaceAll(value, "")))
+	}
+
+	if err := applyCredentials(cmd, n.User, n.Group); err != nil {
+		return fmt.Errorf("failed to apply user/group: %v", err)
+	}
 
-	// Initialize the serverCmd field
-	cmd := exec.Command("node", n.File)
-	cmd.Env = append(os.Environ(), fmt.Sprintf("PORT=%d", n.Port))
+	sinks, stdoutWriter, stderrWriter, err := n.buildWorkerSinks(w)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
 
-	// Set the serverCmd field
-	n.serverCmd = cmd
-	n.serverStopped = false
+	w.mu.Lock()
+	w.cmd = cmd
+	w.logSinks = sinks
+	w.stopping = false
+	w.idleStopped = false
+	w.stopCh = make(chan struct{})
+	w.generation++
+	gen := w.generation
+	w.mu.Unlock()
 
-	// Initialize the serverReady WaitGroup
-	n.serverReady.Add(1)
+	w.ready.Add(1)
 
-	// Start the server
 	if err := cmd.Start(); err != nil {
+		w.ready.Done()
+		for _, sink := range sinks {
+			sink.Close()
+		}
 		return fmt.Errorf("failed to start server: %v", err)
 	}
-	n.logger.Debug("After cmd.Start()")
+	n.logger.Debug("After cmd.Start()", zap.Int("pid", cmd.Process.Pid))
+
+	// Only now, with the process actually running, is the worker no longer
+	// "stopped": nextWorker must not route to it before a respawn attempt
+	// has actually succeeded.
+	w.mu.Lock()
+	w.stopped = false
+	w.mu.Unlock()
+
+	w.pid = cmd.Process.Pid
+	w.addr = fmt.Sprintf("http://localhost:%d", w.port)
+	w.proxy = n.buildProxy(w)
 
-	// Wait for the server to be ready in a separate goroutine
+	// Wait for the worker to pass its HTTP readiness check in a separate
+	// goroutine. A bare TCP dial isn't enough: many frameworks bind the
+	// port before routes or DB pools finish initializing.
 	go func() {
-		n.logger.Debug("Waiting for starting server")
+		n.logger.Debug("Waiting for starting server", zap.Int("port", w.port))
+
+		probeURL := fmt.Sprintf("http://localhost:%d%s", w.port, n.ReadyCheck.Path)
+		probeClient := &http.Client{Timeout: n.ReadyCheck.RequestTimeout}
+		deadline := time.Now().Add(n.ReadyCheck.Timeout)
 
 		for {
-			conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", n.Port))
+			if time.Now().After(deadline) {
+				err := fmt.Errorf("worker on port %d did not pass readiness check %q within %s", w.port, n.ReadyCheck.Path, n.ReadyCheck.Timeout)
+				n.logger.Error(err.Error())
+				w.mu.Lock()
+				w.readyErr = err
+				w.mu.Unlock()
+				w.ready.Done()
+				return
+			}
 
+			resp, err := probeClient.Get(probeURL)
 			if err == nil {
-				n.logger.Debug("Connection: " + conn.LocalAddr().String())
-
-				conn.Close()
-				n.logger.Debug("Server is ready") // Add this line to log when the server is ready
-				n.serverReady.Done()
-				break
+				resp.Body.Close()
+				if resp.StatusCode == n.ReadyCheck.Status {
+					n.logger.Debug("Server is ready", zap.Int("port", w.port))
+					w.ready.Done()
+					return
+				}
+				n.logger.Debug("Server not ready yet, unexpected status", zap.Int("port", w.port), zap.Int("status", resp.StatusCode))
+			} else {
+				n.logger.Debug("Server not ready yet, retrying...", zap.Int("port", w.port))
 			}
-			n.logger.Debug("Server not ready yet, retrying...") // Add this line to log when the server is not ready
-			time.Sleep(100 * time.Millisecond)
+			time.Sleep(n.ReadyCheck.Interval)
 		}
 	}()
-	// Set the server address
-	n.serverAddr = fmt.Sprintf("http://localhost:%d", n.Port)
 
-	n.logger.Debug(fmt.Sprintf("Started server with Pid: %d", n.serverCmd.Process.Pid))
-	// Start monitoring the idle time of the server
-	go n.monitorIdleTime(n.serverCmd.Process.Pid)
+	n.logger.Debug(fmt.Sprintf("Started worker with Pid: %d", w.pid))
 
-	// Set the log rotation duration; you can customize this value as needed
-	n.LogRotationDuration = 1 * time.Hour
+	go n.monitorIdleTime(w, gen)
+	go n.watchWorker(w)
 
-	// Initialize the log file map
-	n.LogFileMap = make(map[int]*os.File)
+	return nil
+}
 
-	if err := n.createLogFile(n.serverCmd.Process.Pid); err != nil {
-		return err
+// buildWorkerSinks builds the configured log_output sinks for a worker and
+// returns the stdout/stderr writers that fan out to them. Node's own
+// stdout/stderr always keep bridging into Caddy's zap logger (at info/error
+// level respectively) alongside whatever sinks are configured; with no
+// log_output directive at all, it falls back to the historical single
+// timestamped file per worker.
+func (n *Nodejs) buildWorkerSinks(w *worker) ([]logSink, io.Writer, io.Writer, error) {
+	files := n.LogOutput.Files
+	if len(files) == 0 && len(n.LogOutput.Syslogs) == 0 && !n.LogOutput.Stdout && !n.LogOutput.Stderr {
+		files = []fileSinkConfig{{
+			Path:        fmt.Sprintf("%s_server.log", filepath.Base(filepath.Dir(n.File))),
+			RotateEvery: n.LogRotationDuration,
+			Keep:        24,
+		}}
 	}
 
-	timeStampedLogFile := &TimeStampedWriter{underlying: n.LogFileMap[n.serverCmd.Process.Pid]}
-	stdoutLogWriter := &LogWriter{logger: n.logger, level: zap.InfoLevel}
-	stderrLogWriter := &LogWriter{logger: n.logger, level: zap.ErrorLevel}
+	var sinks []logSink
+	var common []io.Writer
 
-	n.serverCmd.Stdout = io.MultiWriter(timeStampedLogFile, stdoutLogWriter)
-	n.serverCmd.Stderr = io.MultiWriter(timeStampedLogFile, stderrLogWriter)
+	for _, fc := range files {
+		fs, err := newFileSink(perWorkerPath(fc.Path, w.port), fc.RotateEvery, fc.Keep)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		sinks = append(sinks, fs)
+		common = append(common, &TimeStampedWriter{underlying: fs})
+	}
 
-	go n.rotateLogs(n.serverCmd.Process.Pid)
+	for _, sc := range n.LogOutput.Syslogs {
+		s, err := newSyslogSink(sc.Network, sc.Addr, sc.Facility, sc.Tag)
+		if err != nil {
+			n.logger.Error("Failed to dial syslog sink", zap.String("addr", sc.Addr), zap.Error(err))
+			continue
+		}
+		sinks = append(sinks, s)
+		common = append(common, s)
+	}
 
-	return nil
-}
+	stdoutWriters := append(append([]io.Writer{}, common...), &LogWriter{logger: n.logger, level: zap.InfoLevel})
+	if n.LogOutput.Stdout {
+		stdoutWriters = append(stdoutWriters, os.Stdout)
+	}
 
-func (n *Nodejs) createLogFile(pid int) error {
-	parentFolder := filepath.Base(filepath.Dir(n.File))
-	logFileName := fmt.Sprintf("%s_server_%d_%s.log", parentFolder, n.Port, time.Now().Format("20060102"))
-	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to create log file: %v", err)
+	stderrWriters := append(append([]io.Writer{}, common...), &LogWriter{logger: n.logger, level: zap.ErrorLevel})
+	if n.LogOutput.Stderr {
+		stderrWriters = append(stderrWriters, os.Stderr)
 	}
 
-	n.LogFileMap[pid] = logFile
-	return nil
+	return sinks, io.MultiWriter(stdoutWriters...), io.MultiWriter(stderrWriters...), nil
 }
 
-func (n *Nodejs) rotateLogs(pid int) {
-	var logFileCount int
-	for {
-		// Sleep for the log rotation duration
-		time.Sleep(n.LogRotationDuration)
+// watchWorker waits for the child process to exit. If the exit wasn't caused
+// by an intentional stopServer call, it respawns the worker, backing off
+// exponentially between attempts so a crash-looping app doesn't spin the CPU.
+func (n *Nodejs) watchWorker(w *worker) {
+	err := w.cmd.Wait()
 
-		// Close the current log file
-		if logFile, ok := n.LogFileMap[pid]; ok {
-			logFile.Close()
-		}
+	w.mu.Lock()
+	stopping := w.stopping
+	stopCh := w.stopCh
+	w.mu.Unlock()
 
-		// Create a new log file with a name based on the current timestamp
-		if err := n.createLogFile(pid); err != nil {
-			n.logger.Error("Failed to create new log file during rotation", zap.Int("pid", pid), zap.Error(err))
-			continue
+	if stopping {
+		return
+	}
+
+	// Mark the worker unavailable immediately: otherwise nextWorker sees an
+	// always-idle (inflight == 0) worker and keeps routing requests to it for
+	// the whole backoff window, each one failing instead of failing over.
+	w.mu.Lock()
+	w.stopped = true
+	w.mu.Unlock()
+
+	n.logger.Error("Worker exited unexpectedly, respawning", zap.Int("pid", w.pid), zap.Int("port", w.port), zap.Error(err))
+
+	backoff := 500 * time.Millisecond
+	const maxBackoff = 30 * time.Second
+	for {
+		// Recheck on every iteration, not just once at the top: Cleanup can
+		// call stopServer (closing stopCh) while this goroutine is asleep
+		// mid-backoff, and we must not spawn a new child after that.
+		select {
+		case <-time.After(backoff):
+		case <-stopCh:
+			n.logger.Debug("Worker stop requested during respawn backoff, not restarting", zap.Int("port", w.port))
+			return
 		}
 
-		// Delete old log files
-		logFileCount++
-		if logFileCount > 24 {
-			if err := n.deleteOldLogFiles(); err != nil {
-				n.logger.Error("Failed to delete old log files", zap.Error(err))
-			}
-			logFileCount = 1
+		w.mu.Lock()
+		stopping = w.stopping
+		w.mu.Unlock()
+		if stopping {
+			return
 		}
 
-		// Update the serverCmd stdout and stderr to the new log file
-		n.serverMutex.Lock()
-		if n.serverCmd != nil && n.serverCmd.Process != nil && n.serverCmd.Process.Pid == pid {
-			timeStampedLogFile := &TimeStampedWriter{underlying: n.LogFileMap[pid]}
-			stdoutLogWriter := &LogWriter{logger: n.logger, level: zap.InfoLevel}
-			stderrLogWriter := &LogWriter{logger: n.logger, level: zap.ErrorLevel}
+		n.workersMu.Lock()
+		respawnErr := n.startServer(w)
+		n.workersMu.Unlock()
+
+		if respawnErr == nil {
+			n.logger.Info("Worker respawned", zap.Int("port", w.port))
+			return
+		}
 
-			n.serverCmd.Stdout = io.MultiWriter(timeStampedLogFile, stdoutLogWriter)
-			n.serverCmd.Stderr = io.MultiWriter(timeStampedLogFile, stderrLogWriter)
+		n.logger.Error("Failed to respawn worker, backing off", zap.Int("port", w.port), zap.Duration("backoff", backoff), zap.Error(respawnErr))
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
 		}
-		n.serverMutex.Unlock()
 	}
 }
 
-func (n *Nodejs) deleteOldLogFiles() error {
-	files, err := filepath.Glob(fmt.Sprintf("%s_server_%d_*.log", filepath.Base(filepath.Dir(n.File)), n.Port))
-	if err != nil {
-		return err
+// killProcessTree forcefully kills the worker. On Windows, os.Kill only
+// terminates the immediate process and leaves grandchildren (e.g. a `node`
+// launcher's workers) running, so we shell out to taskkill with /T to reap
+// the whole tree. Elsewhere only the worker's own pid is sent SIGKILL; its
+// children are not signalled.
+func (n *Nodejs) killProcessTree(pid int) {
+	if runtime.GOOS == "windows" {
+		if err := exec.Command("taskkill", "/PID", strconv.Itoa(pid), "/T", "/F").Run(); err != nil {
+			n.logger.Error("Failed to taskkill process tree", zap.Int("pid", pid), zap.Error(err))
+		}
+		return
 	}
-	sort.Strings(files)
-	for i := 0; i < len(files)-24; i++ {
-		err := os.Remove(files[i])
-		if err != nil {
-			return err
+	if process, err := os.FindProcess(pid); err == nil {
+		if err := process.Signal(os.Kill); err != nil {
+			n.logger.Error("Failed to kill the process", zap.Int("pid", pid), zap.Error(err))
 		}
 	}
-	return nil
 }
 
-func (n *Nodejs) stopServer(pid int, lockAcquired bool) {
-	n.logger.Debug("Stopping server", zap.Int("pid", pid))
-	if !lockAcquired {
-		n.serverMutex.Lock()
-		defer n.serverMutex.Unlock()
+func (n *Nodejs) shutdownTimeout() time.Duration {
+	if n.ShutdownTimeout > 0 {
+		return n.ShutdownTimeout
 	}
+	return 5 * time.Second
+}
 
-	// Find the process with the specified process ID
-	process, err := os.FindProcess(pid)
-	if err == nil {
-		n.logger.Debug("Found process", zap.Int("pid", process.Pid))
-		// First, try to send an os.Interrupt signal
-		var signal os.Signal
-		if runtime.GOOS == "windows" {
-			signal = os.Kill
-		} else {
-			signal = os.Interrupt
+// stopServer gracefully terminates a worker: SIGTERM (taskkill /T /F on
+// Windows, since os.Kill there only reaps the immediate process), wait up to
+// the configured shutdown timeout, then escalate to a forceful kill.
+func (n *Nodejs) stopServer(w *worker) {
+	w.mu.Lock()
+	w.stopping = true
+	cmd := w.cmd
+	if w.stopCh != nil {
+		close(w.stopCh)
+	}
+	w.mu.Unlock()
+
+	n.logger.Debug("Stopping worker", zap.Int("pid", w.pid), zap.Int("port", w.port))
+
+	if runtime.GOOS == "windows" {
+		if err := exec.Command("taskkill", "/PID", strconv.Itoa(w.pid), "/T", "/F").Run(); err != nil {
+			n.logger.Error("Failed to taskkill process tree", zap.Error(err))
 		}
-		err := process.Signal(signal)
-		if err != nil {
-			n.logger.Error("Failed to send interrupt signal", zap.Error(err))
-			// If os.Interrupt fails, try to send an os.Kill signal
-			err = process.Signal(os.Kill)
-			if err != nil {
-				n.logger.Error("Failed to send kill signal", zap.Error(err))
-			}
+	} else if process, err := os.FindProcess(w.pid); err == nil {
+		n.logger.Debug("Found process", zap.Int("pid", process.Pid))
+		if err := process.Signal(syscall.SIGTERM); err != nil {
+			n.logger.Error("Failed to send SIGTERM", zap.Error(err))
+			n.killProcessTree(w.pid)
 		}
 	} else {
 		n.logger.Error("Failed to find process", zap.Error(err))
 	}
 
-	// Wait for the serverCmd process to exit with a timeout
-	if n.serverCmd != nil && n.serverCmd.Process != nil && n.serverCmd.Process.Pid == pid {
+	if cmd != nil && cmd.Process != nil {
 		done := make(chan struct{})
 		go func() {
-			_, err := n.serverCmd.Process.Wait()
-			if err != nil {
+			if _, err := cmd.Process.Wait(); err != nil {
 				n.logger.Error("Error waiting for process", zap.Error(err))
 			}
 			close(done)
 		}()
 		select {
-		case <-time.After(5 * time.Second): // Adjust timeout duration as needed
-			n.logger.Error("Waiting for server to stop timed out")
-			if err := process.Signal(os.Kill); err != nil {
-				n.logger.Error("Failed to kill the process", zap.Error(err))
-			}
+		case <-time.After(n.shutdownTimeout()):
+			n.logger.Error("Waiting for worker to stop timed out, killing", zap.Int("pid", w.pid))
+			n.killProcessTree(w.pid)
 		case <-done:
-			n.logger.Debug("Server stopped", zap.Int("pid", pid))
-			n.serverStopped = true
+			n.logger.Debug("Worker stopped", zap.Int("pid", w.pid))
 		}
-	} else {
-		n.logger.Debug("Server not found or mismatching PIDs", zap.Int("serverCmd pid", n.serverCmd.Process.Pid), zap.Int("pid", pid))
 	}
 
-	// Close the log file for the stopped process
-	if logFile, ok := n.LogFileMap[pid]; ok {
-		logFile.Close()
-		delete(n.LogFileMap, pid)
+	w.mu.Lock()
+	w.stopped = true
+	sinks := w.logSinks
+	w.logSinks = nil
+	w.mu.Unlock()
+
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			n.logger.Error("Failed to close log sink", zap.Error(err))
+		}
 	}
+}
 
-	n.logger.Debug("Server stopped", zap.Int("pid", pid))
+// Cleanup is called by Caddy when the handler is being torn down (config
+// reload or process shutdown). It drains in-flight requests, then stops
+// every worker in the pool instead of leaving them orphaned.
+func (n *Nodejs) Cleanup(ctx caddy.Context) error {
+	n.logger.Info("Shutting down nodejs handler")
+
+	drained := make(chan struct{})
+	go func() {
+		n.drainWg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		n.logger.Debug("In-flight requests drained")
+	case <-time.After(n.shutdownTimeout()):
+		n.logger.Warn("Timed out waiting for in-flight requests to drain")
+	}
+
+	n.workersMu.Lock()
+	workers := append([]*worker(nil), n.workers...)
+	n.workersMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			n.stopServer(w)
+		}(w)
+	}
+	wg.Wait()
+
+	if n.accessLog != nil {
+		if err := n.accessLog.Close(); err != nil {
+			n.logger.Error("Failed to close access log", zap.Error(err))
+		}
+	}
+
+	return nil
 }
 
-func (n *Nodejs) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
-	n.logger.Debug("Handling request")
+// nextWorker picks the worker with the fewest in-flight requests, breaking
+// ties round-robin so load spreads evenly across an idle pool. It does not
+// check readiness; ServeHTTP waits for the chosen worker to become ready.
+func (n *Nodejs) nextWorker() *worker {
+	n.workersMu.Lock()
+	defer n.workersMu.Unlock()
 
-	if n.serverCmd == nil {
-		n.logger.Debug("n.serverCmd is nil")
-	} else if n.serverCmd.ProcessState == nil {
-		n.logger.Debug("n.serverCmd.ProcessState is nil")
-	} else {
-		n.logger.Debug("n.serverCmd state", zap.String("state", n.serverCmd.ProcessState.String()))
+	if len(n.workers) == 0 {
+		return nil
 	}
 
-	n.serverMutex.Lock()
+	start := int(atomic.AddUint64(&n.roundRobin, 1)) % len(n.workers)
+	var best *worker
+	for i := 0; i < len(n.workers); i++ {
+		w := n.workers[(start+i)%len(n.workers)]
+		w.mu.Lock()
+		stopped := w.stopped
+		idleStopped := w.idleStopped
+		w.mu.Unlock()
+		// A crash-looping or mid-teardown worker stays out of rotation, but
+		// an idle-stopped one is deliberately still selectable so ServeHTTP's
+		// lazy-restart branch below can bring it back for the next request.
+		if stopped && !idleStopped {
+			continue
+		}
+		if best == nil || atomic.LoadInt32(&w.inflight) < atomic.LoadInt32(&best.inflight) {
+			best = w
+		}
+	}
+	return best
+}
+
+func (n *Nodejs) ServeHTTP(w2 http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	n.logger.Debug("Handling request")
 
-	if n.serverCmd == nil || n.serverStopped || (n.serverCmd.ProcessState != nil && n.serverCmd.ProcessState.Exited()) {
-		n.logger.Debug("Starting new server")
-		err := n.startServer()
+	target := n.nextWorker()
+	if target == nil {
+		return fmt.Errorf("no node.js workers available")
+	}
+
+	// Re-check stopped under workersMu before restarting: two requests can
+	// both see the same idle-stopped worker from nextWorker, and only one of
+	// them should actually call startServer on it.
+	n.workersMu.Lock()
+	target.mu.Lock()
+	stopped := target.stopped
+	target.mu.Unlock()
+	if stopped {
+		err := n.startServer(target)
 		if err != nil {
+			n.workersMu.Unlock()
 			return fmt.Errorf("failed to start node.js server: %v", err)
 		}
+	}
+	n.workersMu.Unlock()
 
-		select {
-		case <-time.After(5 * time.Second): // Adjust timeout duration as needed
-			n.logger.Debug("Waiting for n.serverReady timed out")
-			return fmt.Errorf("waiting for server to be ready timed out")
-		case <-func() chan struct{} {
-			done := make(chan struct{})
-			go func() {
-				n.serverReady.Wait()
-				close(done)
-			}()
-			return done
-		}():
-			n.logger.Debug("Waiting done for n.serverReady")
+	select {
+	case <-time.After(n.ReadyCheck.Timeout + n.ReadyCheck.Interval):
+		n.logger.Debug("Waiting for worker to become ready timed out")
+		return caddyhttp.Error(http.StatusServiceUnavailable, fmt.Errorf("waiting for server to be ready timed out"))
+	case <-func() chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			target.ready.Wait()
+			close(done)
+		}()
+		return done
+	}():
+		n.logger.Debug("Waiting done for worker readiness")
+		if err := target.err(); err != nil {
+			return caddyhttp.Error(http.StatusServiceUnavailable, err)
 		}
 	}
 
-	// Update the lastActive field every time a request is handled
-	n.lastActive = time.Now()
+	atomic.AddInt32(&target.inflight, 1)
+	defer atomic.AddInt32(&target.inflight, -1)
+	target.touch()
 
-	n.serverMutex.Unlock()
+	// Tracked so Cleanup can drain in-flight requests before killing workers.
+	n.drainWg.Add(1)
+	defer n.drainWg.Done()
 
-	n.logger.Debug("Starting new request: " + n.serverAddr + r.URL.Path)
-	proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, n.serverAddr+r.URL.Path, r.Body)
-	if err != nil {
-		return err
+	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		r.Header.Add("X-Forwarded-For", clientIP)
 	}
-	proxyReq.Header = r.Header
-	var httpClient = &http.Client{
-		Timeout: 10 * time.Second,
+	if r.TLS != nil {
+		r.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		r.Header.Set("X-Forwarded-Proto", "http")
 	}
+	r.Header.Set("X-Forwarded-Host", r.Host)
 
-	resp, err := httpClient.Do(proxyReq)
-	if err != nil {
-		return fmt.Errorf("failed to proxy request: %v", err)
+	n.logger.Debug("Proxying request: " + target.addr + r.URL.Path)
+
+	if n.accessLog == nil {
+		target.proxy.ServeHTTP(w2, r)
+		return nil
 	}
-	defer resp.Body.Close()
 
-	// Add this block of logging
-	n.logger.Debug("Received response from Node.js server",
-		zap.Int("status", resp.StatusCode),
-		zap.Any("headers", resp.Header),
-	)
+	rec := &statusRecorder{ResponseWriter: w2, status: http.StatusOK}
+	start := time.Now()
+	target.proxy.ServeHTTP(rec, r)
+	n.writeAccessLog(r, rec.status, rec.bytes, time.Since(start))
 
-	for header, values := range resp.Header {
-		for _, value := range values {
-			w.Header().Add(header, value)
-		}
+	return nil
+}
+
+// statusRecorder wraps a ResponseWriter to capture the status code and byte
+// count for the access log, while passing through Hijack/Flush so WebSocket
+// upgrades and streaming responses proxied through it keep working.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("underlying ResponseWriter does not support hijacking")
 	}
-	w.WriteHeader(resp.StatusCode)
-	_, err = io.Copy(w, resp.Body)
-	if err != nil {
-		n.logger.Error("Failed to copy response", zap.Error(err))
-		return fmt.Errorf("failed to copy response: %v", err)
+	return hijacker.Hijack()
+}
+
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
 	}
+}
 
-	return nil
+type accessLogEntry struct {
+	Time     string `json:"time"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Status   int    `json:"status"`
+	Duration string `json:"duration"`
+	Bytes    int64  `json:"bytes"`
 }
 
-func (n *Nodejs) monitorIdleTime(pid int) {
+// writeAccessLog records a proxied request as a JSON line, independent of
+// whatever the Node.js process itself writes to stdout/stderr.
+func (n *Nodejs) writeAccessLog(r *http.Request, status int, bytes int64, duration time.Duration) {
+	entry := accessLogEntry{
+		Time:     time.Now().Format(time.RFC3339),
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Status:   status,
+		Duration: duration.String(),
+		Bytes:    bytes,
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		n.logger.Error("Failed to marshal access log entry", zap.Error(err))
+		return
+	}
+	line = append(line, '\n')
+	if _, err := n.accessLog.Write(line); err != nil {
+		n.logger.Error("Failed to write access log entry", zap.Error(err))
+	}
+}
+
+// monitorIdleTime idle-stops the worker once it's gone quiet for n.timeout.
+// gen pins this goroutine to the worker generation startServer spawned it
+// for: a crash respawn bumps w.generation without this goroutine's knowledge,
+// and without the check below every respawn would leave the previous
+// generation's monitor running forever, each one liable to idle-stop a
+// worker it no longer actually owns.
+func (n *Nodejs) monitorIdleTime(w *worker, gen int) {
 	for {
 		time.Sleep(60 * time.Second)
-		n.logger.Debug("Checking if server is idle", zap.Int("pid", pid))
-		n.serverMutex.Lock()
-		if time.Since(n.lastActive) > n.timeout {
-			n.stopServer(pid, true)
-			n.serverMutex.Unlock()
-			break
+		n.logger.Debug("Checking if worker is idle", zap.Int("pid", w.pid), zap.Int("port", w.port))
+
+		w.mu.Lock()
+		stopped := w.stopped
+		superseded := w.generation != gen
+		w.mu.Unlock()
+		if stopped || superseded {
+			return
+		}
+
+		if time.Since(w.idleSince()) > n.timeout {
+			// Unlike a crash-stopped worker, an idle-stopped one is meant to
+			// come back on the next request: mark it so nextWorker/ServeHTTP
+			// can tell the two apart instead of filtering it out forever.
+			w.mu.Lock()
+			w.idleStopped = true
+			w.mu.Unlock()
+			n.stopServer(w)
+			return
 		}
-		n.serverMutex.Unlock()
 	}
 }
 
@@ -395,6 +833,240 @@ func parseCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error)
 					return nil, h.Errf("invalid port: %v", err)
 				}
 				n.Port = port
+			case "workers":
+				if !h.NextArg() {
+					return nil, h.ArgErr()
+				}
+				workers, err := strconv.Atoi(h.Val())
+				if err != nil {
+					return nil, h.Errf("invalid workers count: %v", err)
+				}
+				n.Workers = workers
+			case "shutdown_timeout":
+				if !h.NextArg() {
+					return nil, h.ArgErr()
+				}
+				d, err := caddy.ParseDuration(h.Val())
+				if err != nil {
+					return nil, h.Errf("invalid shutdown_timeout: %v", err)
+				}
+				n.ShutdownTimeout = d
+			case "ready_check":
+				for nesting := h.Nesting(); h.NextBlock(nesting); {
+					switch h.Val() {
+					case "path":
+						if !h.AllArgs(&n.ReadyCheck.Path) {
+							return nil, h.ArgErr()
+						}
+					case "status":
+						if !h.NextArg() {
+							return nil, h.ArgErr()
+						}
+						status, err := strconv.Atoi(h.Val())
+						if err != nil {
+							return nil, h.Errf("invalid ready_check status: %v", err)
+						}
+						n.ReadyCheck.Status = status
+					case "timeout":
+						if !h.NextArg() {
+							return nil, h.ArgErr()
+						}
+						d, err := caddy.ParseDuration(h.Val())
+						if err != nil {
+							return nil, h.Errf("invalid ready_check timeout: %v", err)
+						}
+						n.ReadyCheck.Timeout = d
+					case "interval":
+						if !h.NextArg() {
+							return nil, h.ArgErr()
+						}
+						d, err := caddy.ParseDuration(h.Val())
+						if err != nil {
+							return nil, h.Errf("invalid ready_check interval: %v", err)
+						}
+						n.ReadyCheck.Interval = d
+					case "request_timeout":
+						if !h.NextArg() {
+							return nil, h.ArgErr()
+						}
+						d, err := caddy.ParseDuration(h.Val())
+						if err != nil {
+							return nil, h.Errf("invalid ready_check request_timeout: %v", err)
+						}
+						n.ReadyCheck.RequestTimeout = d
+					default:
+						return nil, h.Errf("unrecognized ready_check parameter '%s'", h.Val())
+					}
+				}
+			case "dial_timeout":
+				if !h.NextArg() {
+					return nil, h.ArgErr()
+				}
+				d, err := caddy.ParseDuration(h.Val())
+				if err != nil {
+					return nil, h.Errf("invalid dial_timeout: %v", err)
+				}
+				n.DialTimeout = d
+			case "response_header_timeout":
+				if !h.NextArg() {
+					return nil, h.ArgErr()
+				}
+				d, err := caddy.ParseDuration(h.Val())
+				if err != nil {
+					return nil, h.Errf("invalid response_header_timeout: %v", err)
+				}
+				n.ResponseHeaderTimeout = d
+			case "idle_conn_timeout":
+				if !h.NextArg() {
+					return nil, h.ArgErr()
+				}
+				d, err := caddy.ParseDuration(h.Val())
+				if err != nil {
+					return nil, h.Errf("invalid idle_conn_timeout: %v", err)
+				}
+				n.ProxyIdleTimeout = d
+			case "idle_timeout":
+				if !h.NextArg() {
+					return nil, h.ArgErr()
+				}
+				d, err := caddy.ParseDuration(h.Val())
+				if err != nil {
+					return nil, h.Errf("invalid idle_timeout: %v", err)
+				}
+				n.IdleTimeout = d
+			case "log_rotation":
+				if !h.NextArg() {
+					return nil, h.ArgErr()
+				}
+				d, err := caddy.ParseDuration(h.Val())
+				if err != nil {
+					return nil, h.Errf("invalid log_rotation: %v", err)
+				}
+				n.LogRotation = d
+			case "node_bin":
+				if !h.AllArgs(&n.NodeBin) {
+					return nil, h.ArgErr()
+				}
+			case "args":
+				args := h.RemainingArgs()
+				if len(args) == 0 {
+					return nil, h.ArgErr()
+				}
+				n.Args = args
+			case "cwd":
+				if !h.AllArgs(&n.Cwd) {
+					return nil, h.ArgErr()
+				}
+			case "env":
+				args := h.RemainingArgs()
+				if len(args) != 2 {
+					return nil, h.ArgErr()
+				}
+				if n.Env == nil {
+					n.Env = make(map[string]string)
+				}
+				n.Env[args[0]] = args[1]
+			case "user":
+				if !h.AllArgs(&n.User) {
+					return nil, h.ArgErr()
+				}
+			case "group":
+				if !h.AllArgs(&n.Group) {
+					return nil, h.ArgErr()
+				}
+			case "log_output":
+				for nesting := h.Nesting(); h.NextBlock(nesting); {
+					switch h.Val() {
+					case "file":
+						args := h.RemainingArgs()
+						if len(args) != 1 {
+							return nil, h.ArgErr()
+						}
+						fc := fileSinkConfig{Path: args[0]}
+						for subNesting := h.Nesting(); h.NextBlock(subNesting); {
+							switch h.Val() {
+							case "rotate_every":
+								if !h.NextArg() {
+									return nil, h.ArgErr()
+								}
+								d, err := caddy.ParseDuration(h.Val())
+								if err != nil {
+									return nil, h.Errf("invalid rotate_every: %v", err)
+								}
+								fc.RotateEvery = d
+							case "keep":
+								if !h.NextArg() {
+									return nil, h.ArgErr()
+								}
+								keep, err := strconv.Atoi(h.Val())
+								if err != nil {
+									return nil, h.Errf("invalid keep: %v", err)
+								}
+								fc.Keep = keep
+							default:
+								return nil, h.Errf("unrecognized file sink parameter '%s'", h.Val())
+							}
+						}
+						n.LogOutput.Files = append(n.LogOutput.Files, fc)
+					case "syslog":
+						args := h.RemainingArgs()
+						if len(args) != 2 {
+							return nil, h.ArgErr()
+						}
+						sc := syslogSinkConfig{Network: args[0], Addr: args[1]}
+						for subNesting := h.Nesting(); h.NextBlock(subNesting); {
+							switch h.Val() {
+							case "facility":
+								if !h.AllArgs(&sc.Facility) {
+									return nil, h.ArgErr()
+								}
+							case "tag":
+								if !h.AllArgs(&sc.Tag) {
+									return nil, h.ArgErr()
+								}
+							default:
+								return nil, h.Errf("unrecognized syslog sink parameter '%s'", h.Val())
+							}
+						}
+						n.LogOutput.Syslogs = append(n.LogOutput.Syslogs, sc)
+					case "stdout":
+						n.LogOutput.Stdout = true
+					case "stderr":
+						n.LogOutput.Stderr = true
+					default:
+						return nil, h.Errf("unrecognized log_output parameter '%s'", h.Val())
+					}
+				}
+			case "access_log":
+				args := h.RemainingArgs()
+				if len(args) != 1 {
+					return nil, h.ArgErr()
+				}
+				n.AccessLog.Path = args[0]
+				for nesting := h.Nesting(); h.NextBlock(nesting); {
+					switch h.Val() {
+					case "rotate_every":
+						if !h.NextArg() {
+							return nil, h.ArgErr()
+						}
+						d, err := caddy.ParseDuration(h.Val())
+						if err != nil {
+							return nil, h.Errf("invalid rotate_every: %v", err)
+						}
+						n.AccessLog.RotateEvery = d
+					case "keep":
+						if !h.NextArg() {
+							return nil, h.ArgErr()
+						}
+						keep, err := strconv.Atoi(h.Val())
+						if err != nil {
+							return nil, h.Errf("invalid keep: %v", err)
+						}
+						n.AccessLog.Keep = keep
+					default:
+						return nil, h.Errf("unrecognized access_log parameter '%s'", h.Val())
+					}
+				}
 			default:
 				return nil, h.Errf("unrecognized parameter '%s'", h.Val())
 			}
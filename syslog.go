@@ -0,0 +1,110 @@
+//go:build !windows && !plan9 && !js
+
+package nodejs
+
+import (
+	"log/syslog"
+	"strings"
+	"sync"
+)
+
+// syslogSink ships Node.js output to a remote or local syslog daemon,
+// redialing on the next write after a connection drop rather than giving up.
+type syslogSink struct {
+	network  string
+	addr     string
+	priority syslog.Priority
+	tag      string
+
+	mu     sync.Mutex
+	writer *syslog.Writer
+}
+
+func newSyslogSink(network, addr, facility, tag string) (*syslogSink, error) {
+	s := &syslogSink{
+		network:  network,
+		addr:     addr,
+		priority: syslogFacility(facility) | syslog.LOG_INFO,
+		tag:      tag,
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *syslogSink) connect() error {
+	w, err := syslog.Dial(s.network, s.addr, s.priority, s.tag)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.writer = w
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *syslogSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	w := s.writer
+	s.mu.Unlock()
+
+	if w == nil {
+		if err := s.connect(); err != nil {
+			return len(p), nil
+		}
+		s.mu.Lock()
+		w = s.writer
+		s.mu.Unlock()
+	}
+
+	if _, err := w.Write(p); err != nil {
+		s.mu.Lock()
+		s.writer = nil
+		s.mu.Unlock()
+		return len(p), nil
+	}
+	return len(p), nil
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writer == nil {
+		return nil
+	}
+	return s.writer.Close()
+}
+
+func syslogFacility(name string) syslog.Priority {
+	switch strings.ToLower(name) {
+	case "kern":
+		return syslog.LOG_KERN
+	case "mail":
+		return syslog.LOG_MAIL
+	case "daemon":
+		return syslog.LOG_DAEMON
+	case "auth":
+		return syslog.LOG_AUTH
+	case "syslog":
+		return syslog.LOG_SYSLOG
+	case "local0":
+		return syslog.LOG_LOCAL0
+	case "local1":
+		return syslog.LOG_LOCAL1
+	case "local2":
+		return syslog.LOG_LOCAL2
+	case "local3":
+		return syslog.LOG_LOCAL3
+	case "local4":
+		return syslog.LOG_LOCAL4
+	case "local5":
+		return syslog.LOG_LOCAL5
+	case "local6":
+		return syslog.LOG_LOCAL6
+	case "local7":
+		return syslog.LOG_LOCAL7
+	default:
+		return syslog.LOG_USER
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows || plan9 || js
+
+package nodejs
+
+import "fmt"
+
+// syslogSink is unavailable on this platform; log/syslog doesn't support it.
+type syslogSink struct{}
+
+func newSyslogSink(network, addr, facility, tag string) (*syslogSink, error) {
+	return nil, fmt.Errorf("syslog log_output sink is not supported on this platform")
+}
+
+func (s *syslogSink) Write(p []byte) (int, error) { return len(p), nil }
+func (s *syslogSink) Close() error                { return nil }